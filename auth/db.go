@@ -8,9 +8,12 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base32"
+	"io"
 	"strings"
+	"time"
 
 	"github.com/zeebo/errs"
+	"golang.org/x/crypto/hkdf"
 
 	"storj.io/common/encryption"
 	"storj.io/common/storj"
@@ -84,23 +87,87 @@ func ToBase32(versionByte byte, k []byte) string {
 	return strings.ToLower(base32Encoding.EncodeToString(keyWithMagic))
 }
 
+// KeyRing maps a KeyVersion to the EncryptionKey used to wrap records written
+// under that version. It lets operators rotate the server-side wrapping key
+// without having to re-encrypt every existing record at once: old records
+// stay readable under the version they were written with, and are upgraded
+// to the current version opportunistically as they're read (see Database.Get).
+type KeyRing struct {
+	keys    map[byte]EncryptionKey
+	current byte
+}
+
+// NewKeyRing constructs a KeyRing from a set of versioned wrapping keys.
+// current selects which entry new records are wrapped with; every entry in
+// keys remains available so records written under older versions keep
+// decrypting.
+func NewKeyRing(current byte, keys map[byte]EncryptionKey) (*KeyRing, error) {
+	if current == 0 {
+		return nil, errs.New("key version 0 is reserved for pre-rotation records")
+	}
+	if _, ok := keys[current]; !ok {
+		return nil, errs.New("key ring missing current key version %d", current)
+	}
+	return &KeyRing{keys: keys, current: current}, nil
+}
+
+// Current returns the key version and wrapping key new records should use.
+func (kr *KeyRing) Current() (version byte, key EncryptionKey) {
+	return kr.current, kr.keys[kr.current]
+}
+
+// Wrapping returns the wrapping key for version, and whether it is known.
+func (kr *KeyRing) Wrapping(version byte) (key EncryptionKey, ok bool) {
+	key, ok = kr.keys[version]
+	return key, ok
+}
+
+// deriveRecordKeyInfo is the HKDF info parameter for deriveRecordKey. Binding
+// it to a fixed, purpose-specific string keeps this derivation's output
+// independent of any other use of the same wrapping/client key pair.
+var deriveRecordKeyInfo = []byte("storj-gateway-mt record key v1")
+
+// deriveRecordKey combines a server-side wrapping key with the client-chosen
+// EncryptionKey via HKDF-SHA256, so that record encryption depends on both
+// and the wrapping key can be rotated independently of any individual client
+// key.
+func deriveRecordKey(wrappingKey, clientKey EncryptionKey) (storj.Key, error) {
+	var derivedKey storj.Key
+	kdf := hkdf.New(sha256.New, clientKey[:], wrappingKey[:], deriveRecordKeyInfo)
+	if _, err := io.ReadFull(kdf, derivedKey[:]); err != nil {
+		return storj.Key{}, errs.Wrap(err)
+	}
+	return derivedKey, nil
+}
+
 // Database wraps a key/value store and uses it to store encrypted accesses and secrets.
 type Database struct {
 	kv                        KV
 	allowedSatelliteAddresses map[string]struct{}
+	keyRing                   *KeyRing
+	verifier                  Verifier
 }
 
 // NewDatabase constructs a Database. allowedSatelliteAddresses should contain
 // the full URL (without a node ID), including port, for which satellites we
-// allow for incoming access grants.
-func NewDatabase(kv KV, allowedSatelliteAddresses []string) *Database {
+// allow for incoming access grants. keyRing may be nil, in which case records
+// are written in the legacy unwrapped format (KeyVersion 0). verifier may be
+// nil, in which case a NoopVerifier is used and Put stores any access grant
+// that parses and matches the allow-list, without checking liveness against
+// its satellite.
+func NewDatabase(kv KV, allowedSatelliteAddresses []string, keyRing *KeyRing, verifier Verifier) *Database {
 	m := make(map[string]struct{}, len(allowedSatelliteAddresses))
 	for _, sat := range allowedSatelliteAddresses {
 		m[sat] = struct{}{}
 	}
+	if verifier == nil {
+		verifier = NoopVerifier{}
+	}
 	return &Database{
 		kv:                        kv,
 		allowedSatelliteAddresses: m,
+		keyRing:                   keyRing,
+		verifier:                  verifier,
 	}
 }
 
@@ -123,52 +190,126 @@ func (db *Database) Put(ctx context.Context, key EncryptionKey, accessGrant stri
 	secretKey SecretKey, err error) {
 	defer mon.Task()(&ctx)(&err)
 
-	access, err := access2.ParseAccess(accessGrant)
+	secretKey, record, err := db.buildRecord(ctx, key, accessGrant, public)
+	if err != nil {
+		return secretKey, err
+	}
+
+	if err := db.kv.Put(ctx, key.Hash(), record); err != nil {
+		return secretKey, errs.Wrap(err)
+	}
+
+	return secretKey, nil
+}
+
+// PutWithExpiration behaves like Put, except the record becomes unreachable
+// through Get once expiresAt has passed.
+func (db *Database) PutWithExpiration(ctx context.Context, key EncryptionKey, accessGrant string, public bool, expiresAt time.Time) (
+	secretKey SecretKey, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	secretKey, record, err := db.buildRecord(ctx, key, accessGrant, public)
 	if err != nil {
 		return secretKey, err
 	}
+	record.ExpiresAt = expiresAt
+
+	if err := db.kv.PutWithTTL(ctx, key.Hash(), record, expiresAt); err != nil {
+		return secretKey, errs.Wrap(err)
+	}
+
+	return secretKey, nil
+}
+
+// buildRecord validates accessGrant against the allow-list and verifier, and
+// builds the Record that Put/PutWithExpiration write to the KV.
+func (db *Database) buildRecord(ctx context.Context, key EncryptionKey, accessGrant string, public bool) (
+	secretKey SecretKey, record *Record, err error) {
+	access, err := access2.ParseAccess(accessGrant)
+	if err != nil {
+		return secretKey, nil, err
+	}
 
 	// Check that the satellite address embedded in the access grant is on the
 	// allowed list.
 	satelliteAddr := access.SatelliteAddress
 	url, err := storj.ParseNodeURL(satelliteAddr)
 	if err != nil {
-		return secretKey, err
+		return secretKey, nil, err
 	}
 	if _, ok := db.allowedSatelliteAddresses[url.Address]; !ok {
-		return secretKey, errs.New("access grant contains disallowed satellite '%s'", satelliteAddr)
+		return secretKey, nil, errs.New("access grant contains disallowed satellite '%s'", satelliteAddr)
 	}
 
-	if _, err := rand.Read(secretKey[:]); err != nil {
-		return secretKey, err
+	if err := db.verifier.VerifyAccess(ctx, access); err != nil {
+		return secretKey, nil, err
 	}
 
-	storjKey := key.ToStorjKey()
-	// note that we currently always use the same nonce here - all zero's for secret keys
-	encryptedSecretKey, err := encryption.Encrypt(secretKey[:], storj.EncAESGCM, &storjKey, &storj.Nonce{})
-	if err != nil {
-		return secretKey, err
+	if _, err := rand.Read(secretKey[:]); err != nil {
+		return secretKey, nil, err
 	}
-	// note that we currently always use the same nonce here - one then all zero's for access grants
-	encryptedAccessGrant, err := encryption.Encrypt([]byte(accessGrant), storj.EncAESGCM, &storjKey, &storj.Nonce{1})
+
+	record, err = db.encryptRecord(key, satelliteAddr, access.APIKey.Head(), public, secretKey[:], []byte(accessGrant))
 	if err != nil {
-		return secretKey, err
+		return secretKey, nil, err
 	}
+	record.CreatedAt = time.Now()
 
-	// TODO: Verify access with satellite.
+	return secretKey, record, nil
+}
+
+// encryptRecord builds the Record for a given secret key and access grant.
+// When db.keyRing is set, the record gets a fresh random nonce per ciphertext
+// and is wrapped under the ring's current key version (KeyVersion != 0).
+// Without a KeyRing, it's written in the legacy KeyVersion 0 format (fixed
+// nonces, unwrapped) so existing deployments keep working until they
+// configure one.
+func (db *Database) encryptRecord(key EncryptionKey, satelliteAddr string, macaroonHead []byte, public bool, secretKey, accessGrant []byte) (*Record, error) {
 	record := &Record{
-		SatelliteAddress:     satelliteAddr,
-		MacaroonHead:         access.APIKey.Head(),
-		EncryptedSecretKey:   encryptedSecretKey,
-		EncryptedAccessGrant: encryptedAccessGrant,
-		Public:               public,
+		SatelliteAddress: satelliteAddr,
+		MacaroonHead:     macaroonHead,
+		Public:           public,
 	}
 
-	if err := db.kv.Put(ctx, key.Hash(), record); err != nil {
-		return secretKey, errs.Wrap(err)
+	if db.keyRing == nil {
+		storjKey := key.ToStorjKey()
+		var err error
+		record.EncryptedSecretKey, err = encryption.Encrypt(secretKey, storj.EncAESGCM, &storjKey, &storj.Nonce{})
+		if err != nil {
+			return nil, err
+		}
+		record.EncryptedAccessGrant, err = encryption.Encrypt(accessGrant, storj.EncAESGCM, &storjKey, &storj.Nonce{1})
+		if err != nil {
+			return nil, err
+		}
+		return record, nil
 	}
 
-	return secretKey, err
+	wrappingKey := EncryptionKey{}
+	record.KeyVersion, wrappingKey = db.keyRing.Current()
+
+	if _, err := rand.Read(record.SecretKeyNonce[:]); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(record.AccessGrantNonce[:]); err != nil {
+		return nil, err
+	}
+
+	derivedKey, err := deriveRecordKey(wrappingKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	record.EncryptedSecretKey, err = encryption.Encrypt(secretKey, storj.EncAESGCM, &derivedKey, &record.SecretKeyNonce)
+	if err != nil {
+		return nil, err
+	}
+	record.EncryptedAccessGrant, err = encryption.Encrypt(accessGrant, storj.EncAESGCM, &derivedKey, &record.AccessGrantNonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return record, nil
 }
 
 // Get retrieves an access grant and secret key from the key/value store, looked up by the
@@ -183,22 +324,91 @@ func (db *Database) Get(ctx context.Context, key EncryptionKey) (accessGrant str
 		return "", false, secretKey, NotFound.New("key hash: %x", key.Hash())
 	}
 
-	storjKey := key.ToStorjKey()
-	// note that we currently always use the same nonce here - all zero's for secret keys
-	sk, err := encryption.Decrypt(record.EncryptedSecretKey, storj.EncAESGCM, &storjKey, &storj.Nonce{})
+	sk, ag, err := db.decryptRecord(key, record)
 	if err != nil {
 		return "", false, secretKey, errs.Wrap(err)
 	}
 	copy(secretKey[:], sk)
-	// note that we currently always use the same nonce here - one then all zero's for access grants
-	ag, err := encryption.Decrypt(record.EncryptedAccessGrant, storj.EncAESGCM, &storjKey, &storj.Nonce{1})
-	if err != nil {
-		return "", false, secretKey, errs.Wrap(err)
+
+	// Opportunistically upgrade legacy and older-version records to the
+	// current key version instead of requiring a bulk migration job.
+	if db.keyRing != nil && record.KeyVersion != db.keyRing.current {
+		db.rewrap(ctx, key, record, sk, ag)
 	}
 
 	return string(ag), record.Public, secretKey, nil
 }
 
+// decryptRecord decrypts the secret key and access grant contained in record.
+// KeyVersion 0 records predate per-record nonces and key wrapping, and are
+// decrypted with the legacy fixed-nonce, unwrapped scheme for backward
+// compatibility.
+func (db *Database) decryptRecord(key EncryptionKey, record *Record) (secretKey, accessGrant []byte, err error) {
+	if record.KeyVersion == 0 {
+		storjKey := key.ToStorjKey()
+		secretKey, err = encryption.Decrypt(record.EncryptedSecretKey, storj.EncAESGCM, &storjKey, &storj.Nonce{})
+		if err != nil {
+			return nil, nil, err
+		}
+		accessGrant, err = encryption.Decrypt(record.EncryptedAccessGrant, storj.EncAESGCM, &storjKey, &storj.Nonce{1})
+		if err != nil {
+			return nil, nil, err
+		}
+		return secretKey, accessGrant, nil
+	}
+
+	if db.keyRing == nil {
+		return nil, nil, errs.New("record requires key version %d but no key ring is configured", record.KeyVersion)
+	}
+	wrappingKey, ok := db.keyRing.Wrapping(record.KeyVersion)
+	if !ok {
+		return nil, nil, errs.New("unknown key version %d", record.KeyVersion)
+	}
+	derivedKey, err := deriveRecordKey(wrappingKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secretKey, err = encryption.Decrypt(record.EncryptedSecretKey, storj.EncAESGCM, &derivedKey, &record.SecretKeyNonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	accessGrant, err = encryption.Decrypt(record.EncryptedAccessGrant, storj.EncAESGCM, &derivedKey, &record.AccessGrantNonce)
+	if err != nil {
+		return nil, nil, err
+	}
+	return secretKey, accessGrant, nil
+}
+
+// rewrap re-encrypts a record under the key ring's current version and
+// writes it back. Failures are recorded as an auth_rewrap_failed monkit event
+// and otherwise ignored: the record is still usable in its current form and
+// will be retried on the next Get.
+func (db *Database) rewrap(ctx context.Context, key EncryptionKey, record *Record, secretKey, accessGrant []byte) {
+	upgraded, err := db.encryptRecord(key, record.SatelliteAddress, record.MacaroonHead, record.Public, secretKey, accessGrant)
+	if err != nil {
+		mon.Event("auth_rewrap_failed")
+		return
+	}
+
+	// encryptRecord only knows about the ciphertexts: carry over the rest of
+	// the old record's metadata so rewrapping can't resurrect an expired
+	// or invalidated access as permanently valid.
+	upgraded.CreatedAt = record.CreatedAt
+	upgraded.ExpiresAt = record.ExpiresAt
+	upgraded.InvalidationReason = record.InvalidationReason
+
+	if !upgraded.ExpiresAt.IsZero() {
+		if err := db.kv.PutWithTTL(ctx, key.Hash(), upgraded, upgraded.ExpiresAt); err != nil {
+			mon.Event("auth_rewrap_failed")
+		}
+		return
+	}
+	if err := db.kv.Put(ctx, key.Hash(), upgraded); err != nil {
+		mon.Event("auth_rewrap_failed")
+	}
+}
+
 // Delete removes any access grant information from the key/value store, looked up by the
 // hash of the key.
 func (db *Database) Delete(ctx context.Context, key EncryptionKey) (err error) {
@@ -214,6 +424,51 @@ func (db *Database) Invalidate(ctx context.Context, key EncryptionKey, reason st
 	return errs.Wrap(db.kv.Invalidate(ctx, key.Hash(), reason))
 }
 
+// List returns up to limit stored records whose KeyHash sorts after cursor,
+// for admin tooling that needs to enumerate accesses. It doesn't decrypt
+// anything.
+func (db *Database) List(ctx context.Context, cursor KeyHash, limit int) (records []KeyHashRecord, nextCursor KeyHash, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	records, nextCursor, err = db.kv.List(ctx, cursor, limit)
+	return records, nextCursor, errs.Wrap(err)
+}
+
+// RecordMeta is the non-sensitive metadata about a stored access grant,
+// returned by Database.Info without decrypting the access grant or secret
+// key.
+type RecordMeta struct {
+	SatelliteAddress   string
+	MacaroonHead       []byte
+	Public             bool
+	CreatedAt          time.Time
+	ExpiresAt          time.Time
+	InvalidationReason string
+}
+
+// Info returns metadata about the record stored for key, without decrypting
+// it, so callers can check whether an access is still valid -- and if not,
+// why -- without needing to present the EncryptionKey to any decryption path.
+func (db *Database) Info(ctx context.Context, key EncryptionKey) (meta *RecordMeta, err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	record, err := db.kv.Get(ctx, key.Hash())
+	if err != nil {
+		return nil, errs.Wrap(err)
+	} else if record == nil {
+		return nil, NotFound.New("key hash: %x", key.Hash())
+	}
+
+	return &RecordMeta{
+		SatelliteAddress:   record.SatelliteAddress,
+		MacaroonHead:       record.MacaroonHead,
+		Public:             record.Public,
+		CreatedAt:          record.CreatedAt,
+		ExpiresAt:          record.ExpiresAt,
+		InvalidationReason: record.InvalidationReason,
+	}, nil
+}
+
 // Ping attempts to do a DB roundtrip. If it can't it will return an error.
 func (db *Database) Ping(ctx context.Context) (err error) {
 	defer mon.Task()(&ctx)(&err)
@@ -0,0 +1,271 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package auth
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zeebo/errs"
+
+	"storj.io/common/macaroon"
+	"storj.io/common/storj"
+	"storj.io/uplink/private/access2"
+)
+
+// fakeKV is a minimal in-memory KV, standing in for a real backend in tests.
+type fakeKV struct {
+	mu      sync.Mutex
+	records map[KeyHash]*Record
+}
+
+func newFakeKV() *fakeKV {
+	return &fakeKV{records: make(map[KeyHash]*Record)}
+}
+
+func (kv *fakeKV) Put(ctx context.Context, keyHash KeyHash, record *Record) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	stored := *record
+	kv.records[keyHash] = &stored
+	return nil
+}
+
+func (kv *fakeKV) PutWithTTL(ctx context.Context, keyHash KeyHash, record *Record, expiresAt time.Time) error {
+	stored := *record
+	stored.ExpiresAt = expiresAt
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.records[keyHash] = &stored
+	return nil
+}
+
+func (kv *fakeKV) Get(ctx context.Context, keyHash KeyHash) (*Record, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	record, ok := kv.records[keyHash]
+	if !ok {
+		return nil, nil
+	}
+	got := *record
+	return &got, nil
+}
+
+func (kv *fakeKV) List(ctx context.Context, cursor KeyHash, limit int) ([]KeyHashRecord, KeyHash, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	var out []KeyHashRecord
+	for hash, record := range kv.records {
+		got := *record
+		out = append(out, KeyHashRecord{KeyHash: hash, Record: &got})
+		if len(out) == limit {
+			break
+		}
+	}
+	return out, KeyHash{}, nil
+}
+
+func (kv *fakeKV) Delete(ctx context.Context, keyHash KeyHash) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	delete(kv.records, keyHash)
+	return nil
+}
+
+func (kv *fakeKV) Invalidate(ctx context.Context, keyHash KeyHash, reason string) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	record, ok := kv.records[keyHash]
+	if !ok {
+		return NotFound.New("key hash: %x", keyHash)
+	}
+	record.InvalidationReason = reason
+	return nil
+}
+
+func (kv *fakeKV) Ping(ctx context.Context) error {
+	return nil
+}
+
+// stubVerifier returns err from every VerifyAccess call.
+type stubVerifier struct {
+	err error
+}
+
+func (v *stubVerifier) VerifyAccess(ctx context.Context, access *access2.Access) error {
+	return v.err
+}
+
+// newTestAccessGrant builds a serialized access grant for satelliteAddr
+// without contacting a satellite, for tests that only need something
+// access2.ParseAccess can round-trip.
+func newTestAccessGrant(t *testing.T, satelliteAddr string) string {
+	t.Helper()
+
+	apiKey, err := macaroon.NewAPIKey([]byte("test-secret"))
+	require.NoError(t, err)
+
+	access := &access2.Access{
+		SatelliteAddress: satelliteAddr,
+		APIKey:           apiKey,
+		EncAccess:        access2.NewEncryptionAccessWithDefaultKey(new(storj.Key)),
+	}
+	grant, err := access.Serialize()
+	require.NoError(t, err)
+	return grant
+}
+
+func TestNewKeyRing(t *testing.T) {
+	key1, err := NewEncryptionKey()
+	require.NoError(t, err)
+
+	_, err = NewKeyRing(0, map[byte]EncryptionKey{1: key1})
+	require.Error(t, err, "key version 0 must be rejected")
+
+	_, err = NewKeyRing(2, map[byte]EncryptionKey{1: key1})
+	require.Error(t, err, "current version must be present in keys")
+
+	ring, err := NewKeyRing(1, map[byte]EncryptionKey{1: key1})
+	require.NoError(t, err)
+
+	version, key := ring.Current()
+	require.EqualValues(t, 1, version)
+	require.Equal(t, key1, key)
+
+	_, ok := ring.Wrapping(9)
+	require.False(t, ok)
+
+	wrapped, ok := ring.Wrapping(1)
+	require.True(t, ok)
+	require.Equal(t, key1, wrapped)
+}
+
+func TestEncryptDecryptRecord(t *testing.T) {
+	key, err := NewEncryptionKey()
+	require.NoError(t, err)
+
+	secretKey := bytes.Repeat([]byte{0x42}, 32)
+	accessGrant := []byte("fake-access-grant-bytes")
+
+	t.Run("legacy record has no key ring", func(t *testing.T) {
+		db := &Database{}
+
+		record, err := db.encryptRecord(key, "sat.test:7777", []byte("head"), false, secretKey, accessGrant)
+		require.NoError(t, err)
+		require.EqualValues(t, 0, record.KeyVersion)
+
+		gotSecret, gotAccess, err := db.decryptRecord(key, record)
+		require.NoError(t, err)
+		require.Equal(t, secretKey, gotSecret)
+		require.Equal(t, accessGrant, gotAccess)
+	})
+
+	t.Run("versioned record round-trips under its key ring", func(t *testing.T) {
+		wrappingKey, err := NewEncryptionKey()
+		require.NoError(t, err)
+		ring, err := NewKeyRing(1, map[byte]EncryptionKey{1: wrappingKey})
+		require.NoError(t, err)
+		db := &Database{keyRing: ring}
+
+		record, err := db.encryptRecord(key, "sat.test:7777", []byte("head"), false, secretKey, accessGrant)
+		require.NoError(t, err)
+		require.EqualValues(t, 1, record.KeyVersion)
+		require.NotZero(t, record.SecretKeyNonce)
+		require.NotZero(t, record.AccessGrantNonce)
+
+		gotSecret, gotAccess, err := db.decryptRecord(key, record)
+		require.NoError(t, err)
+		require.Equal(t, secretKey, gotSecret)
+		require.Equal(t, accessGrant, gotAccess)
+
+		_, _, err = (&Database{}).decryptRecord(key, record)
+		require.Error(t, err, "decrypting a versioned record needs a key ring")
+
+		record.KeyVersion = 9
+		_, _, err = db.decryptRecord(key, record)
+		require.Error(t, err, "decrypting an unknown key version must fail")
+	})
+}
+
+func TestGetOpportunisticRewrap(t *testing.T) {
+	ctx := context.Background()
+	kv := newFakeKV()
+
+	key, err := NewEncryptionKey()
+	require.NoError(t, err)
+
+	secretKey := bytes.Repeat([]byte{0x24}, 32)
+	accessGrant := []byte("legacy-access-grant-bytes")
+
+	legacy, err := (&Database{}).encryptRecord(key, "sat.test:7777", []byte("head"), false, secretKey, accessGrant)
+	require.NoError(t, err)
+	require.EqualValues(t, 0, legacy.KeyVersion)
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	legacy.InvalidationReason = "revoked: compromised key"
+	require.NoError(t, kv.PutWithTTL(ctx, key.Hash(), legacy, expiresAt))
+
+	wrappingKey, err := NewEncryptionKey()
+	require.NoError(t, err)
+	ring, err := NewKeyRing(1, map[byte]EncryptionKey{1: wrappingKey})
+	require.NoError(t, err)
+	db := NewDatabase(kv, nil, ring, nil)
+
+	gotAccessGrant, gotPublic, gotSecretKey, err := db.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, string(accessGrant), gotAccessGrant)
+	require.False(t, gotPublic)
+	require.Equal(t, secretKey, gotSecretKey[:])
+
+	rewrapped, err := kv.Get(ctx, key.Hash())
+	require.NoError(t, err)
+	require.EqualValues(t, 1, rewrapped.KeyVersion, "Get must rewrap legacy records under the current key version")
+	require.Equal(t, "revoked: compromised key", rewrapped.InvalidationReason, "rewrap must not drop the invalidation reason")
+	require.True(t, expiresAt.Equal(rewrapped.ExpiresAt), "rewrap must not drop the TTL")
+}
+
+func TestPutVerifierWiring(t *testing.T) {
+	ctx := context.Background()
+	const satelliteAddr = "sat.test:7777"
+	accessGrant := newTestAccessGrant(t, satelliteAddr)
+
+	key, err := NewEncryptionKey()
+	require.NoError(t, err)
+
+	t.Run("rejected verification stores nothing", func(t *testing.T) {
+		kv := newFakeKV()
+		db := NewDatabase(kv, []string{satelliteAddr}, nil, &stubVerifier{err: errs.New("api key revoked")})
+
+		_, err := db.Put(ctx, key, accessGrant, false)
+		require.Error(t, err)
+
+		stored, err := kv.Get(ctx, key.Hash())
+		require.NoError(t, err)
+		require.Nil(t, stored)
+	})
+
+	t.Run("accepted verification stores the record", func(t *testing.T) {
+		kv := newFakeKV()
+		db := NewDatabase(kv, []string{satelliteAddr}, nil, &stubVerifier{})
+
+		_, err := db.Put(ctx, key, accessGrant, false)
+		require.NoError(t, err)
+
+		stored, err := kv.Get(ctx, key.Hash())
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+	})
+
+	t.Run("nil verifier defaults to accepting everything", func(t *testing.T) {
+		kv := newFakeKV()
+		db := NewDatabase(kv, []string{satelliteAddr}, nil, nil)
+
+		_, err := db.Put(ctx, key, accessGrant, false)
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,78 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/spacemonkeygo/monkit/v3"
+
+	"storj.io/common/storj"
+)
+
+var mon = monkit.Package()
+
+// KeyHash is the hash of an EncryptionKey, used as the lookup key in a KV store.
+type KeyHash [32]byte
+
+// Record is an encrypted access grant and secret key, as stored in a KV.
+type Record struct {
+	SatelliteAddress     string
+	MacaroonHead         []byte
+	EncryptedSecretKey   []byte
+	EncryptedAccessGrant []byte
+	Public               bool
+
+	// KeyVersion identifies which KeyRing entry was combined with the
+	// client-supplied EncryptionKey to produce EncryptedSecretKey and
+	// EncryptedAccessGrant. A zero value means the record predates key
+	// rotation and was encrypted with the legacy fixed-nonce scheme.
+	KeyVersion byte
+
+	// SecretKeyNonce and AccessGrantNonce are the per-record nonces used to
+	// produce EncryptedSecretKey and EncryptedAccessGrant. They are unset
+	// (and unused) on KeyVersion 0 records.
+	SecretKeyNonce   storj.Nonce
+	AccessGrantNonce storj.Nonce
+
+	// CreatedAt is when the record was first written.
+	CreatedAt time.Time
+	// ExpiresAt is when the record stops being reachable through Get, or the
+	// zero Time if it was written without an expiration.
+	ExpiresAt time.Time
+	// InvalidationReason is the reason given to Invalidate, or empty if the
+	// record hasn't been invalidated.
+	InvalidationReason string
+}
+
+// KeyHashRecord pairs a KeyHash with the Record stored under it, as returned
+// by KV.List.
+type KeyHashRecord struct {
+	KeyHash KeyHash
+	Record  *Record
+}
+
+// KV is the key/value store backing a Database.
+type KV interface {
+	// Put stores the record under keyHash, overwriting any existing record.
+	Put(ctx context.Context, keyHash KeyHash, record *Record) error
+	// PutWithTTL stores the record under keyHash like Put, but the backend
+	// must stop returning it from Get once expiresAt has passed.
+	PutWithTTL(ctx context.Context, keyHash KeyHash, record *Record, expiresAt time.Time) error
+	// Get retrieves the record for keyHash, returning nil if it doesn't exist
+	// or has expired.
+	Get(ctx context.Context, keyHash KeyHash) (*Record, error)
+	// List returns up to limit records whose KeyHash sorts after cursor, in
+	// KeyHash order, along with the cursor to pass to resume listing. A zero
+	// nextCursor means there are no more records to list. Passing a zero
+	// cursor starts from the beginning.
+	List(ctx context.Context, cursor KeyHash, limit int) (records []KeyHashRecord, nextCursor KeyHash, err error)
+	// Delete removes the record for keyHash, if it exists.
+	Delete(ctx context.Context, keyHash KeyHash) error
+	// Invalidate marks the record for keyHash as invalid, for the given reason.
+	Invalidate(ctx context.Context, keyHash KeyHash, reason string) error
+	// Ping attempts a roundtrip to confirm the backend is reachable.
+	Ping(ctx context.Context) error
+}
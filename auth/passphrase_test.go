@@ -0,0 +1,58 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package auth
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptionKeyFromPassphrase(t *testing.T) {
+	salt := []byte("a-per-deployment-salt")
+
+	t.Run("empty passphrase is rejected", func(t *testing.T) {
+		_, err := EncryptionKeyFromPassphrase("", salt, 0)
+		require.Error(t, err)
+	})
+
+	t.Run("empty salt is rejected", func(t *testing.T) {
+		_, err := EncryptionKeyFromPassphrase("correct horse battery staple", nil, 0)
+		require.Error(t, err)
+	})
+
+	t.Run("default salt is rejected only in release builds", func(t *testing.T) {
+		_, err := EncryptionKeyFromPassphrase("correct horse battery staple", defaultPassphraseSalt, 0)
+		if isRelease {
+			require.Error(t, err, "release builds must reject the default salt")
+		} else {
+			require.NoError(t, err, "non-release builds may use the default salt for convenience")
+		}
+	})
+
+	t.Run("concurrency 0 falls back to runtime.NumCPU()", func(t *testing.T) {
+		implicit, err := EncryptionKeyFromPassphrase("correct horse battery staple", salt, 0)
+		require.NoError(t, err)
+
+		explicit, err := EncryptionKeyFromPassphrase("correct horse battery staple", salt, runtime.NumCPU())
+		require.NoError(t, err)
+
+		require.Equal(t, explicit, implicit, "concurrency 0 must derive the same key as an explicit runtime.NumCPU()")
+	})
+
+	t.Run("derived key is compatible with the KV lookup path", func(t *testing.T) {
+		key, err := EncryptionKeyFromPassphrase("correct horse battery staple", salt, 0)
+		require.NoError(t, err)
+
+		again, err := EncryptionKeyFromPassphrase("correct horse battery staple", salt, 0)
+		require.NoError(t, err)
+
+		require.Equal(t, key.Hash(), again.Hash(), "re-deriving from the same passphrase and salt must hash to the same KV lookup key")
+
+		other, err := EncryptionKeyFromPassphrase("a different passphrase", salt, 0)
+		require.NoError(t, err)
+		require.NotEqual(t, key.Hash(), other.Hash())
+	})
+}
@@ -0,0 +1,51 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// PassphraseHandler resolves a passphrase to the access key id a client
+// would otherwise have to derive and paste in themselves, so the Auth
+// Service can offer passphrase-based access alongside literal access keys.
+// Mount it at whatever path the Auth Service's router assigns, e.g.
+// POST /v1/access/from-passphrase.
+type PassphraseHandler struct{}
+
+type passphraseRequest struct {
+	Passphrase string `json:"passphrase"`
+	Salt       string `json:"salt"`
+}
+
+type passphraseResponse struct {
+	AccessKeyID string `json:"accessKeyId"`
+}
+
+// ServeHTTP decodes a {passphrase, salt} JSON request body, derives the
+// matching EncryptionKey, and responds with its base32-encoded access key
+// id, the same one EncryptionKeyFromPassphrase plus ToBase32 would produce
+// for any other caller deriving the same key.
+func (PassphraseHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req passphraseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	key, err := EncryptionKeyFromPassphrase(req.Passphrase, []byte(req.Salt), 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(passphraseResponse{AccessKeyID: key.ToBase32()})
+}
@@ -0,0 +1,10 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+//go:build release
+
+package auth
+
+// isRelease is true when built with the "release" build tag, as used for
+// production gateway-mt builds.
+const isRelease = true
@@ -0,0 +1,9 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+//go:build !release
+
+package auth
+
+// isRelease is false unless built with the "release" build tag.
+const isRelease = false
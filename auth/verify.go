@@ -0,0 +1,78 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/zeebo/errs"
+
+	"storj.io/common/rpc/rpcpool"
+	"storj.io/uplink"
+	"storj.io/uplink/private/access2"
+	"storj.io/uplink/private/transport"
+)
+
+// VerificationFailed is returned by a Verifier when an access grant is no
+// longer usable on its satellite, e.g. because its API key was revoked or its
+// project was deleted. Database.Put surfaces it as a distinct class so the
+// HTTP layer can respond 403 instead of 500.
+var VerificationFailed = errs.Class("access verification failed")
+
+// Verifier confirms that an access grant is still live on its satellite
+// before Database.Put stores it.
+type Verifier interface {
+	VerifyAccess(ctx context.Context, access *access2.Access) error
+}
+
+// NoopVerifier accepts every access grant without contacting a satellite. It
+// is the default used by NewDatabase, preserving the prior behavior of
+// storing anything that parses and matches the allow-list.
+type NoopVerifier struct{}
+
+// VerifyAccess always succeeds.
+func (NoopVerifier) VerifyAccess(ctx context.Context, access *access2.Access) error {
+	return nil
+}
+
+// SatelliteVerifier verifies access grants by opening a project against the
+// satellite embedded in the grant: a revoked API key or a deleted project
+// causes that to fail, while a live one succeeds cheaply.
+type SatelliteVerifier struct {
+	pool        *rpcpool.Pool
+	dialTimeout time.Duration
+}
+
+// NewSatelliteVerifier constructs a SatelliteVerifier. pool is reused across
+// verifications so the auth service doesn't reopen a TLS connection per
+// registration; dialTimeout bounds how long a single verification may take.
+func NewSatelliteVerifier(pool *rpcpool.Pool, dialTimeout time.Duration) *SatelliteVerifier {
+	return &SatelliteVerifier{pool: pool, dialTimeout: dialTimeout}
+}
+
+// VerifyAccess opens a project with access against its satellite to confirm
+// the embedded macaroon is still live, returning VerificationFailed if not.
+func (v *SatelliteVerifier) VerifyAccess(ctx context.Context, access *access2.Access) (err error) {
+	defer mon.Task()(&ctx)(&err)
+
+	ctx, cancel := context.WithTimeout(ctx, v.dialTimeout)
+	defer cancel()
+	ctx = transport.SetConnectionPool(ctx, v.pool)
+
+	grant, err := access2.Serialize(access)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+	fullAccess, err := uplink.ParseAccess(grant)
+	if err != nil {
+		return errs.Wrap(err)
+	}
+
+	project, err := (uplink.Config{DialTimeout: v.dialTimeout}).OpenProject(ctx, fullAccess)
+	if err != nil {
+		return VerificationFailed.Wrap(err)
+	}
+	return errs.Wrap(project.Close())
+}
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"net"
 	"os"
@@ -24,12 +25,21 @@ import (
 
 	"storj.io/common/fpath"
 	"storj.io/common/rpc/rpcpool"
+	"storj.io/common/storj"
+	gwauth "storj.io/gateway-mt/auth"
 	"storj.io/gateway-mt/internal/wizard"
 	"storj.io/gateway-mt/miniogw"
 	"storj.io/gateway-mt/pkg/server"
 	"storj.io/private/cfgstruct"
 	"storj.io/private/process"
 	"storj.io/uplink"
+	"storj.io/uplink/private/access2"
+)
+
+// Auth modes accepted by GatewayFlags.Auth.Mode.
+const (
+	authModeRemote = "remote"
+	authModeLocal  = "local"
 )
 
 // GatewayFlags configuration flags.
@@ -44,9 +54,33 @@ type GatewayFlags struct {
 	InsecureDisableTLS        bool     `help:"listen using insecure connections" releaseDefault:"false" devDefault:"true"`
 	DomainName                string   `help:"domain suffix used in TLS certificates" releaseDefault:"" devDefault:"localhost" basic-help:"true"`
 
+	Auth AuthConfig
+
 	Config
 }
 
+// AuthConfig controls how the gateway authenticates incoming S3 access keys.
+type AuthConfig struct {
+	Mode string `help:"access key authentication mode: remote (use the Auth Service) or local (accept an access grant directly as the access key)" default:"remote"`
+
+	Access            string   `help:"uplink access grant to use as the sole access key, for --auth.mode=local" default:""`
+	AccessFile        string   `help:"path to a file containing an uplink access grant, for --auth.mode=local" default:""`
+	AllowedSatellites []string `help:"satellite addresses allowed in access grants, for --auth.mode=local" default:""`
+}
+
+// resolveAccess returns the access grant configured for --auth.mode=local,
+// preferring AccessFile over the literal Access value.
+func (a AuthConfig) resolveAccess() (string, error) {
+	if a.AccessFile != "" {
+		data, err := os.ReadFile(a.AccessFile)
+		if err != nil {
+			return "", Error.Wrap(err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return a.Access, nil
+}
+
 // ClientConfig is a configuration struct for the uplink that controls how
 // to talk to the rest of the network.
 type ClientConfig struct {
@@ -56,6 +90,18 @@ type ClientConfig struct {
 // Config uplink configuration.
 type Config struct {
 	Client ClientConfig
+	Enc    EncryptionConfig
+}
+
+// EncryptionConfig lets an operator hand out a memorable passphrase instead
+// of a base32 access key id: the gateway derives the matching id once at
+// startup with auth.EncryptionKeyFromPassphrase and logs it, mirroring what
+// the Auth Service's auth.PassphraseHandler derives per-request for clients
+// that only hold the passphrase.
+type EncryptionConfig struct {
+	Passphrase       string `help:"passphrase to derive the access key id from, instead of printing the literal access key" default:""`
+	Salt             string `help:"per-deployment salt for --enc.passphrase; required if --enc.passphrase is set" default:""`
+	PBKDFConcurrency int    `help:"number of threads to use when deriving the access key id from --enc.passphrase; 0 uses the number of CPUs" default:"0"`
 }
 
 var (
@@ -81,7 +127,8 @@ var (
 	setupCfg GatewayFlags
 	runCfg   GatewayFlags
 
-	confDir string
+	confDir        string
+	nonInteractive bool
 )
 
 func init() {
@@ -93,6 +140,7 @@ func init() {
 	rootCmd.AddCommand(setupCmd)
 	process.Bind(runCmd, &runCfg, defaults, cfgstruct.ConfDir(confDir))
 	process.Bind(setupCmd, &setupCfg, defaults, cfgstruct.ConfDir(confDir), cfgstruct.SetupMode())
+	setupCmd.Flags().BoolVar(&nonInteractive, "non-interactive", false, "don't prompt for setup values interactively; require them as flags and skip tracing prompts")
 
 	rootCmd.PersistentFlags().BoolVar(new(bool), "advanced", false, "if used in with -h, print advanced flags help")
 	cfgstruct.SetBoolAnnotation(rootCmd.PersistentFlags(), "advanced", cfgstruct.BasicHelpAnnotationName, true)
@@ -144,26 +192,101 @@ func cmdRun(cmd *cobra.Command, args []string) (err error) {
 	set := func(value, envName string) {
 		err = errs.Combine(err, Error.Wrap(os.Setenv(envName, value)))
 	}
-	validate(runCfg.AuthToken, "auth-token")
-	validate(runCfg.AuthURL, "auth-url")
+	switch runCfg.Auth.Mode {
+	case authModeRemote:
+		validate(runCfg.AuthToken, "auth-token")
+		validate(runCfg.AuthURL, "auth-url")
+	case authModeLocal:
+		if runCfg.Auth.Access == "" && runCfg.Auth.AccessFile == "" {
+			err = errs.Combine(err, Error.New("--auth.mode=local requires --auth.access or --auth.access-file"))
+		}
+	default:
+		err = errs.Combine(err, Error.New("unknown --auth.mode %q, must be %q or %q", runCfg.Auth.Mode, authModeRemote, authModeLocal))
+	}
 	validate(runCfg.DomainName, "domain-name")
 	set(runCfg.DomainName, "MINIO_DOMAIN")
 	set("enable", "STORJ_AUTH_ENABLED")
 	set("off", "MINIO_BROWSER")
-	set("dummy-key-to-satisfy-minio", "MINIO_ACCESS_KEY")
-	set("dummy-key-to-satisfy-minio", "MINIO_SECRET_KEY")
+	if err != nil {
+		return err
+	}
+
+	if runCfg.Auth.Mode == authModeLocal {
+		accessGrant, resolveErr := runCfg.Auth.resolveAccess()
+		if resolveErr != nil {
+			return resolveErr
+		}
+		if err := validateLocalAccess(accessGrant, runCfg.Auth.AllowedSatellites); err != nil {
+			return Error.Wrap(err)
+		}
+		set(accessGrant, "MINIO_ACCESS_KEY")
+		set("dummy-key-to-satisfy-minio", "MINIO_SECRET_KEY")
+	} else {
+		set("dummy-key-to-satisfy-minio", "MINIO_ACCESS_KEY")
+		set("dummy-key-to-satisfy-minio", "MINIO_SECRET_KEY")
+	}
 	if err != nil {
 		return err
 	}
 
 	zap.S().Info("Starting Tardigrade S3 Gateway\n\n")
 	zap.S().Infof("Endpoint: %s\n", address)
-	zap.S().Info("Access key: use your Tardigrade Access Grant\n")
+	if runCfg.Enc.Passphrase != "" {
+		accessKeyID, err := accessKeyIDFromPassphrase(runCfg.Enc)
+		if err != nil {
+			return Error.Wrap(err)
+		}
+		zap.S().Infof("Access key: %s (derived from --enc.passphrase)\n", accessKeyID)
+	} else {
+		zap.S().Info("Access key: use your Tardigrade Access Grant\n")
+	}
 	zap.S().Info("Secret key: anything would work\n")
 
 	return runCfg.Run(ctx, address)
 }
 
+// accessKeyIDFromPassphrase derives the access key id a client holding the
+// same passphrase and salt would derive, so operators can hand out a
+// passphrase instead of a literal access key id.
+func accessKeyIDFromPassphrase(enc EncryptionConfig) (string, error) {
+	if enc.Salt == "" {
+		return "", Error.New("--enc.salt is required when --enc.passphrase is set")
+	}
+	key, err := gwauth.EncryptionKeyFromPassphrase(enc.Passphrase, []byte(enc.Salt), enc.PBKDFConcurrency)
+	if err != nil {
+		return "", err
+	}
+	return key.ToBase32(), nil
+}
+
+// validateLocalAccess parses accessGrant and, if allowedSatellites is
+// non-empty, confirms its embedded satellite address is on the list. It
+// reuses auth.RemoveNodeIDs so --auth.mode=local enforces the same allow-list
+// semantics as the Auth Service's own allowed-satellite-addresses check.
+func validateLocalAccess(accessGrant string, allowedSatellites []string) error {
+	access, err := access2.ParseAccess(accessGrant)
+	if err != nil {
+		return err
+	}
+	if len(allowedSatellites) == 0 {
+		return nil
+	}
+	allowed, err := gwauth.RemoveNodeIDs(allowedSatellites)
+	if err != nil {
+		return err
+	}
+	url, err := storj.ParseNodeURL(access.SatelliteAddress)
+	if err != nil {
+		return err
+	}
+	for _, addr := range allowed {
+		if addr == url.Address {
+			return nil
+		}
+	}
+	return errs.New("access grant contains disallowed satellite '%s'", access.SatelliteAddress)
+}
+
 // Run starts a Minio Gateway given proper config.
 func (flags GatewayFlags) Run(ctx context.Context, address string) (err error) {
 	// set object API handler
@@ -181,9 +304,16 @@ func (flags GatewayFlags) Run(ctx context.Context, address string) (err error) {
 	minio.HandleCommonEnvVars()
 	// make Minio not use random ETags
 	minio.SetGlobalCLI(false, true, false, address, true)
-	store := minio.NewIAMStorjAuthStore(newObject, runCfg.AuthURL, runCfg.AuthToken)
 	minio.SetObjectLayer(newObject)
-	minio.InitCustomStore(store, "StorjAuthSys")
+
+	// In local mode there's no Auth Service to ask, so we don't register a
+	// custom IAM store: the access grant itself is the static access key set
+	// in MINIO_ACCESS_KEY by cmdRun, and Minio checks requests against it
+	// directly.
+	if flags.Auth.Mode == authModeRemote {
+		store := minio.NewIAMStorjAuthStore(newObject, flags.AuthURL, flags.AuthToken)
+		minio.InitCustomStore(store, "StorjAuthSys")
+	}
 
 	listener, err := net.Listen("tcp", address)
 	if err != nil {
@@ -235,27 +365,58 @@ func (flags *GatewayFlags) newUplinkConfig(ctx context.Context) uplink.Config {
 	return config
 }
 
-// interactive creates the configuration of the gateway interactively.
+// interactive creates the configuration of the gateway interactively. When
+// nonInteractive is set, it instead requires every needed value to already
+// be present on flags and skips all prompts, so the config file can be
+// generated non-interactively (e.g. from Docker/CI).
 func (flags GatewayFlags) interactive(cmd *cobra.Command, setupDir string) error {
 	overrides := make(map[string]interface{})
 
-	tracingEnabled, err := wizard.PromptForTracing()
-	if err != nil {
-		return Error.Wrap(err)
-	}
-	if tracingEnabled {
-		overrides["tracing.enabled"] = true
-		overrides["tracing.sample"] = 0.1
-		overrides["tracing.interval"] = 30 * time.Second
+	if nonInteractive {
+		if err := flags.validateNonInteractive(); err != nil {
+			return Error.Wrap(err)
+		}
+	} else {
+		tracingEnabled, err := wizard.PromptForTracing()
+		if err != nil {
+			return Error.Wrap(err)
+		}
+		if tracingEnabled {
+			overrides["tracing.enabled"] = true
+			overrides["tracing.sample"] = 0.1
+			overrides["tracing.interval"] = 30 * time.Second
+		}
+
+		if flags.Auth.Mode == authModeLocal && flags.Auth.Access == "" && flags.Auth.AccessFile == "" {
+			accessGrant, err := wizard.PromptForAccessGrant()
+			if err != nil {
+				return Error.Wrap(err)
+			}
+			overrides["auth.access"] = accessGrant
+		}
 	}
 
-	err = process.SaveConfig(cmd, filepath.Join(setupDir, "config.yaml"),
+	configPath := filepath.Join(setupDir, "config.yaml")
+	err := process.SaveConfig(cmd, configPath,
 		process.SaveConfigWithOverrides(overrides),
 		process.SaveConfigRemovingDeprecated())
 	if err != nil {
 		return Error.Wrap(err)
 	}
 
+	if nonInteractive {
+		// Deployment tooling picks up the config path from this, so keep it
+		// on stdout alone and leave the human-facing tips out.
+		summary, err := json.Marshal(struct {
+			ConfigPath string `json:"configPath"`
+		}{ConfigPath: configPath})
+		if err != nil {
+			return Error.Wrap(err)
+		}
+		fmt.Println(string(summary))
+		return nil
+	}
+
 	fmt.Println(`
 Your S3 Gateway is configured and ready to use!
 
@@ -266,14 +427,42 @@ Some things to try next:
 	return nil
 }
 
-/*	`setUsageFunc` is a bit unconventional but cobra didn't leave much room for
-	extensibility here. `cmd.SetUsageTemplate` is fairly useless for our case without
-	the ability to add to the template's function map (see: https://golang.org/pkg/text/template/#hdr-Functions).
+// validateNonInteractive checks that every flag required for a non-prompting
+// setup was actually provided, returning a single error listing everything
+// missing rather than failing on the first one.
+func (flags GatewayFlags) validateNonInteractive() error {
+	var missing []string
+	switch flags.Auth.Mode {
+	case authModeLocal:
+		if flags.Auth.Access == "" && flags.Auth.AccessFile == "" {
+			missing = append(missing, "--auth.access or --auth.access-file")
+		}
+	default:
+		if flags.AuthURL == "" {
+			missing = append(missing, "--auth-url")
+		}
+		if flags.AuthToken == "" {
+			missing = append(missing, "--auth-token")
+		}
+	}
+	if flags.DomainName == "" {
+		missing = append(missing, "--domain-name")
+	}
+	if len(missing) > 0 {
+		return errs.New("non-interactive setup missing required flags: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+/*
+`setUsageFunc` is a bit unconventional but cobra didn't leave much room for
+extensibility here. `cmd.SetUsageTemplate` is fairly useless for our case without
+the ability to add to the template's function map (see: https://golang.org/pkg/text/template/#hdr-Functions).
 
-	Because we can't alter what `cmd.Usage` generates, we have to edit it afterwards.
-	In order to hook this function *and* get the usage string, we have to juggle the
-	`cmd.usageFunc` between our hook and `nil`, so that we can get the usage string
-	from the default usage func.
+Because we can't alter what `cmd.Usage` generates, we have to edit it afterwards.
+In order to hook this function *and* get the usage string, we have to juggle the
+`cmd.usageFunc` between our hook and `nil`, so that we can get the usage string
+from the default usage func.
 */
 func setUsageFunc(cmd *cobra.Command) {
 	if findBoolFlagEarly("advanced") {
@@ -0,0 +1,28 @@
+// Copyright (C) 2020 Storj Labs, Inc.
+// See LICENSE for copying information.
+
+package wizard
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zeebo/errs"
+)
+
+// PromptForAccessGrant prompts the user to paste the uplink access grant to
+// use as the gateway's sole access key in --auth.mode=local, and returns it
+// with surrounding whitespace trimmed.
+func PromptForAccessGrant() (string, error) {
+	reader := bufio.NewReader(os.Stdin)
+
+	fmt.Print("Enter your access grant: ")
+	accessGrant, err := reader.ReadString('\n')
+	if err != nil {
+		return "", errs.Wrap(err)
+	}
+
+	return strings.TrimSpace(accessGrant), nil
+}